@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ctxKey namespaces context values so this package doesn't collide with
+// keys set by net/http or other packages.
+type ctxKey string
+
+const requestIDKey ctxKey = "request_id"
+
+// contextHandler wraps a slog.Handler and stamps every record with the
+// request_id stashed in ctx (if any), so call sites don't have to pass it
+// explicitly at every log line.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// logger emits structured JSON lines (ts, level, msg, plus whatever
+// attributes/context a call site adds) instead of the plain-text lines
+// log.Printf produces.
+var logger = slog.New(contextHandler{slog.NewJSONHandler(os.Stdout, nil)})
+
+func init() {
+	// Log startup message to ensure logging is working.
+	logger.Info("Server initializing...")
+}
+
+// logf logs a formatted, structured message with no request context. Use
+// logCtx/logErrorCtx from request-scoped code instead, so the request_id
+// is attached automatically.
+func logf(format string, v ...interface{}) {
+	logger.Info(fmt.Sprintf(format, v...))
+}
+
+// logCtx is logf's context-aware counterpart: it carries ctx through to
+// the handler so any request_id stashed in it (see withRequestID) is
+// attached to the line.
+func logCtx(ctx context.Context, format string, v ...interface{}) {
+	logger.InfoContext(ctx, fmt.Sprintf(format, v...))
+}
+
+// logErrorCtx is logCtx logged at Error level, for failures encountered
+// while handling a request.
+func logErrorCtx(ctx context.Context, format string, v ...interface{}) {
+	logger.ErrorContext(ctx, fmt.Sprintf(format, v...))
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder wraps a ResponseWriter to capture the status code a
+// handler wrote, since http.ResponseWriter doesn't expose it otherwise.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// loggingMiddleware assigns each request a UUID, stashes it in the
+// request's context so every log line emitted while handling it
+// (including webhook parsing and cache updates) can be correlated back
+// to it, and logs one structured completion line per request.
+func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := uuid.NewString()
+		r = r.WithContext(withRequestID(r.Context(), requestID))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next(rec, r)
+
+		trackCacheMux.RLock()
+		cacheSize := len(trackCache)
+		trackCacheMux.RUnlock()
+
+		logger.InfoContext(r.Context(), "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+			"cache_size", cacheSize,
+		)
+	}
+}
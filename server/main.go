@@ -1,150 +1,42 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
-	"log"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
 
-func init() {
-	// Configure log to write to stdout with timestamps
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime)
-	// Don't use log.Lshortfile as it makes the logs harder to read in journalctl
-	
-	// Log startup message to ensure logging is working
-	log.Println("Server initializing...")
-}
-
-// Helper function to format log messages consistently
-func logf(format string, v ...interface{}) {
-	// Add newline if not present
-	if len(format) == 0 || format[len(format)-1] != '\n' {
-		format += "\n"
-	}
-	// Use log.Printf instead of fmt.Printf to ensure proper journald integration
-	log.Printf(format, v...)
-}
-
-type CloudinaryResource struct {
-	AssetID  string `json:"asset_id"`
-	PublicID string `json:"public_id"`
-	Format   string `json:"format"`
-	Type     string `json:"type"`
-}
-
-type CloudinaryResponse struct {
-	Resources []CloudinaryResource `json:"resources"`
-}
-
-type CloudinaryNotification struct {
-	NotificationType     string    `json:"notification_type"`
-	Timestamp           string    `json:"timestamp,omitempty"`
-	RequestID           string    `json:"request_id,omitempty"`
-	AssetID            string    `json:"asset_id,omitempty"`
-	PublicID           string    `json:"public_id"`
-	ResourceType       string    `json:"resource_type"`
-	Type              string    `json:"type"`
-	Version           int64     `json:"version,omitempty"`
-	Format            string    `json:"format,omitempty"`
-	NotificationContext struct {
-		TriggeredAt  string `json:"triggered_at"`
-		TriggeredBy struct {
-			Source string `json:"source"`
-			ID     string `json:"id"`
-		} `json:"triggered_by"`
-	} `json:"notification_context"`
-}
-
-// Global cache
-var (
-	trackCache     CloudinaryResponse
-	trackCacheMux  sync.RWMutex
-	lastFetchTime  time.Time
-	lastFetchMux   sync.RWMutex
-)
-
-// Fetch tracks from Cloudinary
-func fetchTracks(cloudName, apiKey, apiSecret string) (*CloudinaryResponse, error) {
-	logf("Fetching tracks from Cloudinary (cloud_name: %s)", cloudName)
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// defaultRefreshInterval is how often the periodic refresh loop re-fetches
+// the full track list, as a safety net for missed webhooks. Configurable
+// via REFRESH_INTERVAL (e.g. "5m").
+const defaultRefreshInterval = 10 * time.Minute
+
+// shutdownDrainTimeout bounds how long Shutdown waits for in-flight
+// requests to finish before the process exits on SIGINT/SIGTERM.
+const shutdownDrainTimeout = 10 * time.Second
+
+// refreshIntervalFromEnv reads REFRESH_INTERVAL as a time.Duration string,
+// falling back to defaultRefreshInterval when unset or invalid.
+func refreshIntervalFromEnv() time.Duration {
+	raw := os.Getenv("REFRESH_INTERVAL")
+	if raw == "" {
+		return defaultRefreshInterval
 	}
-
-	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/resources/video", cloudName)
-	logf("Making request to: %s", url)
-
-	req, err := http.NewRequest("GET", url, nil)
+	d, err := time.ParseDuration(raw)
 	if err != nil {
-		logf("Error creating request: %v", err)
-		return nil, err
+		logf("Warning: invalid REFRESH_INTERVAL %q, using default %v", raw, defaultRefreshInterval)
+		return defaultRefreshInterval
 	}
-
-	q := req.URL.Query()
-	q.Add("type", "upload")
-	q.Add("prefix", "my-music/")
-	q.Add("max_results", "100")
-	req.URL.RawQuery = q.Encode()
-	logf("Full request URL: %s", req.URL.String())
-
-	req.SetBasicAuth(apiKey, apiSecret)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		logf("Error making request: %v", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	logf("Cloudinary API response status: %s", resp.Status)
-	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logf("Error reading response body: %v", err)
-		return nil, err
-	}
-	logf("Response body: %s", string(body))
-
-	var result CloudinaryResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		logf("Error parsing JSON response: %v", err)
-		return nil, err
-	}
-
-	logf("Successfully fetched %d tracks", len(result.Resources))
-	return &result, nil
-}
-
-// Update cache
-func updateCache(cloudName, apiKey, apiSecret string) error {
-	logf("Starting cache update...")
-	tracks, err := fetchTracks(cloudName, apiKey, apiSecret)
-	if err != nil {
-		logf("Cache update failed: %v", err)
-		return err
-	}
-
-	trackCacheMux.Lock()
-	defer trackCacheMux.Unlock()
-	
-	logf("Previous cache had %d tracks", len(trackCache.Resources))
-	trackCache = *tracks
-	logf("New cache has %d tracks", len(tracks.Resources))
-
-	lastFetchMux.Lock()
-	lastFetchTime = time.Now()
-	lastFetchMux.Unlock()
-
-	logf("Cache update completed successfully")
-	return nil
+	return d
 }
 
 func main() {
@@ -152,32 +44,23 @@ func main() {
 		logf("Warning: .env file not found")
 	}
 
-	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	apiKey := os.Getenv("CLOUDINARY_API_KEY")
-	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
-
-	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		logf("Fatal: Required environment variables not found")
+	source, err := NewTrackSource()
+	if err != nil {
+		logf("Fatal: %v", err)
 		os.Exit(1)
 	}
+	logf("Track source: %s", source.Name())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Initial cache population
-	if err := updateCache(cloudName, apiKey, apiSecret); err != nil {
+	if err := refreshCache(ctx, source); err != nil {
 		logf("Warning: Initial cache population failed: %v", err)
 	}
 
 	mux := http.NewServeMux()
 
-	// Add a logging middleware
-	loggingMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			log.Printf("REQUEST: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-			next(w, r)
-			log.Printf("COMPLETED: %s %s in %v", r.Method, r.URL.Path, time.Since(start))
-		}
-	}
-
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173", "https://music.nskien.com", "https://music-meta.nskien.com"},
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
@@ -193,103 +76,68 @@ func main() {
 			return
 		}
 
-		trackCacheMux.RLock()
-		response := trackCache
-		trackCacheMux.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshotTracks())
+	}))
+
+	// Search endpoint: filters/sorts/paginates the in-memory cache, no
+	// additional TrackSource calls.
+	mux.HandleFunc("/api/tracks/search", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		params, err := parseSearchParams(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(searchTracks(params))
 	}))
 
-	// Webhook endpoint with enhanced logging
+	// Webhook endpoint
 	mux.HandleFunc("/api/webhook", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("DEBUG: Entering webhook handler")
-		defer log.Println("DEBUG: Exiting webhook handler")
-
 		if r.Method != http.MethodPost {
-			log.Printf("Webhook: Rejected %s method (only POST allowed)", r.Method)
+			logCtx(r.Context(), "Webhook: Rejected %s method (only POST allowed)", r.Method)
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Log headers for debugging
-		log.Println("Webhook: Headers received:")
-		for name, values := range r.Header {
-			log.Printf("  %s: %v", name, values)
-		}
-
-		// Read and log raw body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			log.Printf("Webhook: Error reading body: %v", err)
+			logErrorCtx(r.Context(), "Webhook: Error reading body: %v", err)
 			http.Error(w, "Error reading body", http.StatusBadRequest)
 			return
 		}
-		log.Printf("Webhook: Raw body received: %s", string(body))
-
-		// Parse notification
-		var notification CloudinaryNotification
-		if err := json.Unmarshal(body, &notification); err != nil {
-			log.Printf("Webhook: Error parsing JSON: %v", err)
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		log.Printf("Webhook: Parsed notification: %+v", notification)
+		logCtx(r.Context(), "Webhook: Raw body received: %s", string(body))
 
-		// Check if this is a test request from curl
-		if notification.PublicID == "test" {
-			log.Println("Webhook: Detected test request, updating cache anyway")
-			if err := updateCache(cloudName, apiKey, apiSecret); err != nil {
-				log.Printf("Webhook: Failed to update cache for test: %v", err)
-				http.Error(w, "Failed to update cache", http.StatusInternalServerError)
+		delta, err := source.HandleNotification(r.Context(), body, r.Header)
+		if err != nil {
+			if errors.Is(err, ErrUnauthorizedNotification) {
+				logErrorCtx(r.Context(), "Webhook: Rejected invalid signature: %v", err)
+				recordWebhookRequest(delta.NotificationType, "unauthorized")
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
 				return
 			}
-			log.Println("Webhook: Cache updated successfully for test request")
-			w.WriteHeader(http.StatusOK)
+			logErrorCtx(r.Context(), "Webhook: Error handling notification: %v", err)
+			recordWebhookRequest(delta.NotificationType, "error")
+			http.Error(w, "Invalid notification", http.StatusBadRequest)
 			return
 		}
 
-		// Check if this is a relevant notification (resource_type should be video)
-		if notification.ResourceType != "video" {
-			logf("Webhook: Ignoring non-video resource: %s", notification.ResourceType)
-			w.WriteHeader(http.StatusOK)
+		if err := applyDelta(r.Context(), source, delta); err != nil {
+			logErrorCtx(r.Context(), "Webhook: Failed to update cache: %v", err)
+			recordWebhookRequest(delta.NotificationType, "error")
+			http.Error(w, "Failed to update cache", http.StatusInternalServerError)
 			return
 		}
 
-		// Handle different notification types
-		switch notification.NotificationType {
-		case "upload":
-			logf("Webhook: Handling upload notification for %s", notification.PublicID)
-			if err := updateCache(cloudName, apiKey, apiSecret); err != nil {
-				logf("Webhook: Failed to update cache: %v", err)
-				http.Error(w, "Failed to update cache", http.StatusInternalServerError)
-				return
-			}
-			logf("Webhook: Cache updated successfully for upload: %s", notification.PublicID)
-
-		case "delete":
-			logf("Webhook: Handling delete notification")
-			if err := updateCache(cloudName, apiKey, apiSecret); err != nil {
-				logf("Webhook: Failed to update cache: %v", err)
-				http.Error(w, "Failed to update cache", http.StatusInternalServerError)
-				return
-			}
-			logf("Webhook: Cache updated successfully after delete")
-
-		default:
-			// For any notification type, update the cache anyway
-			logf("Webhook: Handling notification type: %s", notification.NotificationType)
-			if err := updateCache(cloudName, apiKey, apiSecret); err != nil {
-				logf("Webhook: Failed to update cache: %v", err)
-				http.Error(w, "Failed to update cache", http.StatusInternalServerError)
-				return
-			}
-			logf("Webhook: Cache updated successfully for notification type: %s", notification.NotificationType)
-		}
-
+		recordWebhookRequest(delta.NotificationType, "success")
 		w.WriteHeader(http.StatusOK)
-		logf("Webhook: Successfully processed request")
+		logCtx(r.Context(), "Webhook: Successfully processed request")
 	}))
 
 	// Health check endpoint
@@ -298,34 +146,125 @@ func main() {
 		lastFetch := lastFetchTime
 		lastFetchMux.RUnlock()
 
+		trackCacheMux.RLock()
+		cachedTracks := len(trackCache)
+		trackCacheMux.RUnlock()
+
 		response := struct {
-			Status        string    `json:"status"`
+			Status       string    `json:"status"`
+			Source       string    `json:"source"`
 			LastFetch    time.Time `json:"last_fetch"`
 			CachedTracks int       `json:"cached_tracks"`
 		}{
-			Status:     "healthy",
-			LastFetch:  lastFetch,
-			CachedTracks: len(trackCache.Resources),
+			Status:       "healthy",
+			Source:       source.Name(),
+			LastFetch:    lastFetch,
+			CachedTracks: cachedTracks,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	}))
 
+	// Prometheus metrics endpoint
+	mux.HandleFunc("/metrics", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeMetrics(w)
+	}))
+
 	handler := c.Handler(mux)
 
+	// Sources that can detect their own changes (e.g. local filesystem) run
+	// their watch loop in the background for as long as the server is up.
+	if watchable, ok := source.(watchableSource); ok {
+		go func() {
+			if err := watchable.Watch(ctx, func(delta Delta) {
+				if err := applyDelta(ctx, source, delta); err != nil {
+					logf("Watch: failed to apply delta: %v", err)
+				}
+			}); err != nil && !errors.Is(err, context.Canceled) {
+				logf("Watch: %s watcher stopped: %v", source.Name(), err)
+			}
+		}()
+	}
+
+	// Periodic refresh loop: a safety net in case a webhook (or the
+	// filesystem watcher) is missed and the cache goes stale.
+	refreshInterval := refreshIntervalFromEnv()
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		logf("Periodic refresh every %v", refreshInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := refreshCache(ctx, source); err != nil {
+					logf("Periodic refresh failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	tlsCfg, err := loadTLSConfig()
+	if err != nil {
+		logf("Fatal: %v", err)
+		os.Exit(1)
+	}
+
+	httpServer := &http.Server{Addr: ":80", Handler: handler}
+	httpsServer := &http.Server{Addr: ":443", Handler: handler}
+
+	if tlsCfg.autocertMgr != nil {
+		// The HTTP-01 challenge must be reachable on port 80; everything
+		// else there gets redirected to HTTPS. The manual cert-file mode
+		// doesn't need this, since nothing provisions certs over HTTP.
+		httpServer.Handler = tlsCfg.autocertMgr.HTTPHandler(nil)
+		httpsServer.TLSConfig = tlsCfg.autocertMgr.TLSConfig()
+	}
+
+	// Drain in-flight requests on both servers when the root context is
+	// cancelled (SIGINT/SIGTERM), instead of dropping connections outright.
+	go func() {
+		<-ctx.Done()
+		logf("Shutdown signal received, draining connections (up to %v)...", shutdownDrainTimeout)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDrainTimeout)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logf("HTTP server shutdown error: %v", err)
+		}
+		if err := httpsServer.Shutdown(shutdownCtx); err != nil {
+			logf("HTTPS server shutdown error: %v", err)
+		}
+	}()
+
 	// Start HTTP server on port 80
 	go func() {
 		logf("HTTP Server starting on port 80")
-		if err := http.ListenAndServe(":80", handler); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logf("HTTP Server failed: %v", err)
 		}
 	}()
 
 	// Start HTTPS server on port 443
 	logf("HTTPS Server starting on port 443")
-	if err := http.ListenAndServeTLS(":443", "/etc/letsencrypt/live/music-meta.nskien.com/fullchain.pem", "/etc/letsencrypt/live/music-meta.nskien.com/privkey.pem", handler); err != nil {
-		logf("Fatal: HTTPS Server failed to start: %v", err)
+	var serveErr error
+	if tlsCfg.manual {
+		serveErr = httpsServer.ListenAndServeTLS(tlsCfg.certFile, tlsCfg.keyFile)
+	} else {
+		// Cert/key come from httpsServer.TLSConfig (autocert), not files.
+		serveErr = httpsServer.ListenAndServeTLS("", "")
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		logf("Fatal: HTTPS Server failed to start: %v", serveErr)
 		os.Exit(1)
 	}
-} 
\ No newline at end of file
+
+	logf("Server stopped")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metrics are hand-rolled Prometheus text exposition rather than a client
+// library, since the surface here is four simple counters/gauges.
+var (
+	webhookMetricsMux    sync.Mutex
+	webhookRequestsTotal = map[[2]string]int64{} // [type,result] -> count
+
+	fetchMetricsMux   sync.Mutex
+	lastFetchDuration time.Duration
+)
+
+// recordWebhookRequest increments webhook_requests_total for the given
+// notification type ("upload", "delete", "object_removed", ...; "unknown"
+// when the source couldn't determine one) and result ("success", "error",
+// "unauthorized"). Labeling by notification type rather than source name
+// is what lets an operator alert on e.g. a spike in deletes or
+// unauthorized attempts, since a deployment only ever runs one source.
+func recordWebhookRequest(notificationType, result string) {
+	if notificationType == "" {
+		notificationType = "unknown"
+	}
+	webhookMetricsMux.Lock()
+	webhookRequestsTotal[[2]string{notificationType, result}]++
+	webhookMetricsMux.Unlock()
+}
+
+// recordFetchDuration records how long the most recent TrackSource.List
+// call took, published as cloudinary_fetch_duration_seconds.
+func recordFetchDuration(d time.Duration) {
+	fetchMetricsMux.Lock()
+	lastFetchDuration = d
+	fetchMetricsMux.Unlock()
+}
+
+func fetchDurationSeconds() float64 {
+	fetchMetricsMux.Lock()
+	defer fetchMetricsMux.Unlock()
+	return lastFetchDuration.Seconds()
+}
+
+// writeMetrics renders the counters/gauges operators need to alert on a
+// stale cache or a failing TrackSource, in Prometheus text format.
+func writeMetrics(w http.ResponseWriter) {
+	trackCacheMux.RLock()
+	cacheSize := len(trackCache)
+	trackCacheMux.RUnlock()
+
+	lastFetchMux.RLock()
+	lastFetch := lastFetchTime
+	lastFetchMux.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP webhook_requests_total Webhook notifications processed, by notification type and result.")
+	fmt.Fprintln(w, "# TYPE webhook_requests_total counter")
+	webhookMetricsMux.Lock()
+	for k, v := range webhookRequestsTotal {
+		fmt.Fprintf(w, "webhook_requests_total{type=%q,result=%q} %d\n", k[0], k[1], v)
+	}
+	webhookMetricsMux.Unlock()
+
+	fmt.Fprintln(w, "# HELP cloudinary_fetch_duration_seconds Duration of the most recent full track listing fetch.")
+	fmt.Fprintln(w, "# TYPE cloudinary_fetch_duration_seconds gauge")
+	fmt.Fprintf(w, "cloudinary_fetch_duration_seconds %f\n", fetchDurationSeconds())
+
+	fmt.Fprintln(w, "# HELP cache_size Number of tracks currently cached.")
+	fmt.Fprintln(w, "# TYPE cache_size gauge")
+	fmt.Fprintf(w, "cache_size %d\n", cacheSize)
+
+	fmt.Fprintln(w, "# HELP last_fetch_timestamp_seconds Unix timestamp of the last successful cache update.")
+	fmt.Fprintln(w, "# TYPE last_fetch_timestamp_seconds gauge")
+	var ts float64
+	if !lastFetch.IsZero() {
+		ts = float64(lastFetch.Unix())
+	}
+	fmt.Fprintf(w, "last_fetch_timestamp_seconds %f\n", ts)
+}
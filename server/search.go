@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSearchLimit and maxSearchLimit bound the page size for
+// /api/tracks/search so a client can't force us to marshal the entire
+// cache (or an empty one) by accident.
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// SearchResponse is the shape served from /api/tracks/search. Total is the
+// count before limit/offset are applied, so a client can page through it.
+type SearchResponse struct {
+	Resources []Track `json:"resources"`
+	Total     int     `json:"total"`
+}
+
+// searchParams is the parsed, validated form of the /api/tracks/search
+// query string.
+type searchParams struct {
+	query  string
+	tag    string
+	sortBy string
+	order  string
+	limit  int
+	offset int
+}
+
+func parseSearchParams(q url.Values) (searchParams, error) {
+	p := searchParams{
+		query:  strings.TrimSpace(q.Get("q")),
+		tag:    strings.TrimSpace(q.Get("tag")),
+		sortBy: q.Get("sort"),
+		order:  q.Get("order"),
+		limit:  defaultSearchLimit,
+	}
+
+	switch p.sortBy {
+	case "":
+		p.sortBy = "title"
+	case "title", "created_at", "duration":
+	default:
+		return searchParams{}, fmt.Errorf("invalid sort: %s (want title, created_at or duration)", p.sortBy)
+	}
+
+	switch p.order {
+	case "":
+		p.order = "asc"
+	case "asc", "desc":
+	default:
+		return searchParams{}, fmt.Errorf("invalid order: %s (want asc or desc)", p.order)
+	}
+
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return searchParams{}, fmt.Errorf("invalid limit: %s", raw)
+		}
+		p.limit = limit
+	}
+	// Only cap an over-large limit; an explicit limit=0 means "zero rows",
+	// not "unspecified" (that's what an absent limit param already sets to
+	// defaultSearchLimit above).
+	if p.limit > maxSearchLimit {
+		p.limit = maxSearchLimit
+	}
+
+	if raw := q.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return searchParams{}, fmt.Errorf("invalid offset: %s", raw)
+		}
+		p.offset = offset
+	}
+
+	return p, nil
+}
+
+// searchTracks filters, sorts and paginates the in-memory cache. It never
+// calls out to a TrackSource.
+func searchTracks(p searchParams) SearchResponse {
+	trackCacheMux.RLock()
+	tracks := make([]Track, 0, len(trackCache))
+	for _, track := range trackCache {
+		tracks = append(tracks, track)
+	}
+	trackCacheMux.RUnlock()
+
+	tracks = filterTracks(tracks, p)
+	sortTracks(tracks, p.sortBy, p.order)
+
+	total := len(tracks)
+
+	if p.offset >= len(tracks) {
+		return SearchResponse{Resources: []Track{}, Total: total}
+	}
+	end := p.offset + p.limit
+	if end > len(tracks) {
+		end = len(tracks)
+	}
+
+	return SearchResponse{Resources: tracks[p.offset:end], Total: total}
+}
+
+func filterTracks(tracks []Track, p searchParams) []Track {
+	if p.query == "" && p.tag == "" {
+		return tracks
+	}
+
+	query := strings.ToLower(p.query)
+	tag := strings.ToLower(p.tag)
+
+	filtered := tracks[:0]
+	for _, track := range tracks {
+		if query != "" && !matchesQuery(track, query) {
+			continue
+		}
+		if tag != "" && !hasTag(track, tag) {
+			continue
+		}
+		filtered = append(filtered, track)
+	}
+	return filtered
+}
+
+func matchesQuery(track Track, query string) bool {
+	if strings.Contains(strings.ToLower(track.Title), query) {
+		return true
+	}
+	for _, tag := range track.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// createdAtOrZero sorts tracks with an unknown CreatedAt (a nil pointer)
+// as if they were created at the zero time, i.e. first in ascending order.
+func createdAtOrZero(track Track) time.Time {
+	if track.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *track.CreatedAt
+}
+
+func hasTag(track Track, tag string) bool {
+	for _, t := range track.Tags {
+		if strings.ToLower(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func sortTracks(tracks []Track, sortBy, order string) {
+	less := func(i, j int) bool {
+		switch sortBy {
+		case "created_at":
+			return createdAtOrZero(tracks[i]).Before(createdAtOrZero(tracks[j]))
+		case "duration":
+			return tracks[i].Duration < tracks[j].Duration
+		default:
+			return tracks[i].Title < tracks[j].Title
+		}
+	}
+	if order == "desc" {
+		base := less
+		less = func(i, j int) bool { return base(j, i) }
+	}
+	sort.SliceStable(tracks, less)
+}
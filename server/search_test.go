@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSearchParamsDefaults(t *testing.T) {
+	p, err := parseSearchParams(url.Values{})
+	if err != nil {
+		t.Fatalf("parseSearchParams() = %v, want nil", err)
+	}
+	if p.limit != defaultSearchLimit {
+		t.Errorf("limit = %d, want %d", p.limit, defaultSearchLimit)
+	}
+	if p.sortBy != "title" || p.order != "asc" {
+		t.Errorf("sortBy/order = %q/%q, want title/asc", p.sortBy, p.order)
+	}
+}
+
+func TestParseSearchParamsExplicitZeroLimitIsNotPromoted(t *testing.T) {
+	p, err := parseSearchParams(url.Values{"limit": {"0"}})
+	if err != nil {
+		t.Fatalf("parseSearchParams() = %v, want nil", err)
+	}
+	if p.limit != 0 {
+		t.Errorf("limit = %d, want 0 (explicit limit=0 must not become the default)", p.limit)
+	}
+}
+
+func TestParseSearchParamsCapsOverlargeLimit(t *testing.T) {
+	p, err := parseSearchParams(url.Values{"limit": {"10000"}})
+	if err != nil {
+		t.Fatalf("parseSearchParams() = %v, want nil", err)
+	}
+	if p.limit != maxSearchLimit {
+		t.Errorf("limit = %d, want %d", p.limit, maxSearchLimit)
+	}
+}
+
+func TestParseSearchParamsRejectsNegativeLimit(t *testing.T) {
+	if _, err := parseSearchParams(url.Values{"limit": {"-1"}}); err == nil {
+		t.Fatal("parseSearchParams() = nil error, want error for negative limit")
+	}
+}
+
+func TestParseSearchParamsRejectsInvalidSort(t *testing.T) {
+	if _, err := parseSearchParams(url.Values{"sort": {"bogus"}}); err == nil {
+		t.Fatal("parseSearchParams() = nil error, want error for invalid sort")
+	}
+}
+
+func TestSearchTracksExplicitZeroLimitReturnsNoRows(t *testing.T) {
+	resetTrackCache(t, map[string]Track{
+		"my-music/a": {PublicID: "my-music/a", Title: "a"},
+	})
+
+	p, err := parseSearchParams(url.Values{"limit": {"0"}})
+	if err != nil {
+		t.Fatalf("parseSearchParams() = %v, want nil", err)
+	}
+
+	resp := searchTracks(p)
+	if len(resp.Resources) != 0 {
+		t.Errorf("len(Resources) = %d, want 0", len(resp.Resources))
+	}
+	if resp.Total != 1 {
+		t.Errorf("Total = %d, want 1 (limit=0 should not affect the reported total)", resp.Total)
+	}
+}
+
+func TestFilterTracksByTagIsCaseInsensitive(t *testing.T) {
+	tracks := []Track{
+		{Title: "song a", Tags: []string{"Jazz"}},
+		{Title: "song b", Tags: []string{"rock"}},
+	}
+	p := searchParams{tag: "jazz"}
+
+	got := filterTracks(tracks, p)
+	if len(got) != 1 || got[0].Title != "song a" {
+		t.Errorf("filterTracks() = %+v, want only %q", got, "song a")
+	}
+}
+
+func TestFilterTracksByQueryMatchesTitleOrTags(t *testing.T) {
+	tracks := []Track{
+		{Title: "lonesome blues", Tags: []string{"acoustic"}},
+		{Title: "upbeat tune", Tags: []string{"Blues"}},
+		{Title: "no match here", Tags: []string{"rock"}},
+	}
+	p := searchParams{query: "blues"}
+
+	got := filterTracks(tracks, p)
+	if len(got) != 2 {
+		t.Errorf("len(filterTracks()) = %d, want 2", len(got))
+	}
+}
+
+func TestSortTracksByCreatedAtTreatsNilAsZero(t *testing.T) {
+	known := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracks := []Track{
+		{Title: "has date", CreatedAt: &known},
+		{Title: "no date"},
+	}
+
+	sortTracks(tracks, "created_at", "asc")
+
+	if tracks[0].Title != "no date" {
+		t.Errorf("first track = %q, want %q (nil CreatedAt sorts first ascending)", tracks[0].Title, "no date")
+	}
+}
+
+func TestSortTracksByTitleDescending(t *testing.T) {
+	tracks := []Track{{Title: "alpha"}, {Title: "beta"}}
+	sortTracks(tracks, "title", "desc")
+
+	if tracks[0].Title != "beta" || tracks[1].Title != "alpha" {
+		t.Errorf("order = [%q, %q], want [beta, alpha]", tracks[0].Title, tracks[1].Title)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TrackSource abstracts where the music library lives. The Cloudinary
+// implementation is the original behavior; S3/MinIO and local-filesystem
+// implementations let the same server run against self-hosted storage.
+type TrackSource interface {
+	// Name identifies the source for logging and metrics.
+	Name() string
+	// List returns every track currently available from the source.
+	List(ctx context.Context) ([]Track, error)
+	// HandleNotification turns a webhook payload into a cache Delta. It
+	// returns an error for a payload the source can't make sense of at
+	// all (bad signature, unparseable body); a Delta with FullResync set
+	// signals a payload the source understood but can't apply in place.
+	HandleNotification(ctx context.Context, payload []byte, headers http.Header) (Delta, error)
+}
+
+// watchableSource is an optional capability: sources that can detect
+// changes on their own (rather than via a webhook) implement it, and main
+// runs Watch in the background for as long as the server is up. This
+// mirrors the io.Closer-style "optional interface" pattern used elsewhere
+// in the stdlib (e.g. http.Hijacker).
+type watchableSource interface {
+	Watch(ctx context.Context, onDelta func(Delta)) error
+}
+
+// ErrUnauthorizedNotification is returned by HandleNotification when the
+// payload fails the source's authenticity check (e.g. a bad webhook
+// signature). The webhook handler maps it to 401 instead of 400/500.
+var ErrUnauthorizedNotification = fmt.Errorf("notification failed authenticity check")
+
+// NewTrackSource builds the TrackSource selected by the TRACK_SOURCE env
+// var (default "cloudinary"), reading whatever further env vars that
+// source needs.
+func NewTrackSource() (TrackSource, error) {
+	name := os.Getenv("TRACK_SOURCE")
+	if name == "" {
+		name = "cloudinary"
+	}
+
+	switch strings.ToLower(name) {
+	case "cloudinary":
+		return newCloudinarySource()
+	case "s3", "minio":
+		return newS3Source()
+	case "local", "filesystem":
+		return newLocalSource()
+	default:
+		return nil, fmt.Errorf("unknown TRACK_SOURCE: %s", name)
+	}
+}
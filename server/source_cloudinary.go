@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cloudinary signs webhook notifications as sha1(body+timestamp+api_secret)
+// by default; sha256 can be selected via WEBHOOK_SIGNATURE_ALGO for
+// accounts that have opted into the stronger algorithm.
+//
+// defaultWebhookSignatureTolerance bounds how far a notification's
+// timestamp may drift from now before it's rejected as a replay.
+// Configurable via WEBHOOK_SIGNATURE_TOLERANCE (e.g. "5m").
+const defaultWebhookSignatureTolerance = 5 * time.Minute
+
+// webhookSignatureToleranceFromEnv reads WEBHOOK_SIGNATURE_TOLERANCE as a
+// time.Duration string, falling back to defaultWebhookSignatureTolerance
+// when unset or invalid.
+func webhookSignatureToleranceFromEnv() time.Duration {
+	raw := os.Getenv("WEBHOOK_SIGNATURE_TOLERANCE")
+	if raw == "" {
+		return defaultWebhookSignatureTolerance
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		logf("Warning: invalid WEBHOOK_SIGNATURE_TOLERANCE %q, using default %v", raw, defaultWebhookSignatureTolerance)
+		return defaultWebhookSignatureTolerance
+	}
+	return d
+}
+
+type cloudinaryNotification struct {
+	NotificationType    string `json:"notification_type"`
+	Timestamp           string `json:"timestamp,omitempty"`
+	RequestID           string `json:"request_id,omitempty"`
+	AssetID             string `json:"asset_id,omitempty"`
+	PublicID            string `json:"public_id"`
+	FromPublicID        string `json:"from_public_id,omitempty"`
+	ToPublicID          string `json:"to_public_id,omitempty"`
+	ResourceType        string `json:"resource_type"`
+	Type                string `json:"type"`
+	Version             int64  `json:"version,omitempty"`
+	Format              string `json:"format,omitempty"`
+	NotificationContext struct {
+		TriggeredAt string `json:"triggered_at"`
+		TriggeredBy struct {
+			Source string `json:"source"`
+			ID     string `json:"id"`
+		} `json:"triggered_by"`
+	} `json:"notification_context"`
+}
+
+type cloudinaryResponse struct {
+	Resources []cloudinaryResource `json:"resources"`
+}
+
+type cloudinaryResource struct {
+	AssetID   string   `json:"asset_id"`
+	PublicID  string   `json:"public_id"`
+	Format    string   `json:"format"`
+	Type      string   `json:"type"`
+	Duration  float64  `json:"duration,omitempty"`
+	BitRate   int      `json:"bit_rate,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	CreatedAt string   `json:"created_at,omitempty"`
+	Context   struct {
+		Custom map[string]string `json:"custom"`
+	} `json:"context"`
+}
+
+func (r cloudinaryResource) toTrack() Track {
+	track := Track{
+		AssetID:  r.AssetID,
+		PublicID: r.PublicID,
+		Format:   r.Format,
+		Type:     r.Type,
+		Title:    titleFromPublicID(r.PublicID),
+		Duration: r.Duration,
+		Bitrate:  r.BitRate,
+		Tags:     r.Tags,
+		Context:  r.Context.Custom,
+	}
+	if createdAt, err := time.Parse(time.RFC3339, r.CreatedAt); err == nil {
+		track.CreatedAt = &createdAt
+	}
+	return track
+}
+
+// cloudinarySource is the original TrackSource: it lists uploaded videos
+// from the Cloudinary Admin API and consumes Cloudinary webhook
+// notifications.
+type cloudinarySource struct {
+	cloudName string
+	apiKey    string
+	apiSecret string
+
+	signatureAlgo      string
+	signatureDisabled  bool
+	signatureTolerance time.Duration
+}
+
+func newCloudinarySource() (*cloudinarySource, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return nil, fmt.Errorf("CLOUDINARY_CLOUD_NAME, CLOUDINARY_API_KEY and CLOUDINARY_API_SECRET are required")
+	}
+
+	signatureDisabled := os.Getenv("WEBHOOK_SIGNATURE_DISABLED") == "true"
+	if signatureDisabled {
+		logf("Warning: webhook signature verification is disabled, do not use in production")
+	}
+
+	return &cloudinarySource{
+		cloudName:          cloudName,
+		apiKey:             apiKey,
+		apiSecret:          apiSecret,
+		signatureAlgo:      os.Getenv("WEBHOOK_SIGNATURE_ALGO"),
+		signatureDisabled:  signatureDisabled,
+		signatureTolerance: webhookSignatureToleranceFromEnv(),
+	}, nil
+}
+
+func (s *cloudinarySource) Name() string { return "cloudinary" }
+
+func (s *cloudinarySource) List(ctx context.Context) ([]Track, error) {
+	logCtx(ctx, "Fetching tracks from Cloudinary (cloud_name: %s)", s.cloudName)
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/resources/video", s.cloudName)
+	logCtx(ctx, "Making request to: %s", url)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logErrorCtx(ctx, "Error creating request: %v", err)
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Add("type", "upload")
+	q.Add("prefix", "my-music/")
+	q.Add("max_results", "100")
+	q.Add("context", "true")
+	q.Add("tags", "true")
+	req.URL.RawQuery = q.Encode()
+	logCtx(ctx, "Full request URL: %s", req.URL.String())
+
+	req.SetBasicAuth(s.apiKey, s.apiSecret)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logErrorCtx(ctx, "Error making request: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	logCtx(ctx, "Cloudinary API response status: %s", resp.Status)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logErrorCtx(ctx, "Error reading response body: %v", err)
+		return nil, err
+	}
+	logCtx(ctx, "Response body: %s", string(body))
+
+	var result cloudinaryResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		logErrorCtx(ctx, "Error parsing JSON response: %v", err)
+		return nil, err
+	}
+
+	tracks := make([]Track, 0, len(result.Resources))
+	for _, resource := range result.Resources {
+		tracks = append(tracks, resource.toTrack())
+	}
+
+	logCtx(ctx, "Successfully fetched %d tracks", len(tracks))
+	return tracks, nil
+}
+
+// mergeNotificationIntoTrack builds the upsert for an "upload"/"update"
+// notification by starting from whatever the cache already has for this
+// public_id (populated by the last full List) and only overwriting the
+// fields the notification itself carries. Cloudinary notifications don't
+// include duration/bitrate/tags/context/created_at, so building a bare
+// Track from scratch here would erase them until the next periodic
+// refresh.
+func mergeNotificationIntoTrack(n cloudinaryNotification) Track {
+	trackCacheMux.RLock()
+	track, ok := trackCache[n.PublicID]
+	trackCacheMux.RUnlock()
+	if !ok {
+		track = Track{}
+	}
+
+	track.PublicID = n.PublicID
+	track.Title = titleFromPublicID(n.PublicID)
+	if n.AssetID != "" {
+		track.AssetID = n.AssetID
+	}
+	if n.Format != "" {
+		track.Format = n.Format
+	}
+	if n.Type != "" {
+		track.Type = n.Type
+	}
+	return track
+}
+
+func (s *cloudinarySource) HandleNotification(ctx context.Context, payload []byte, headers http.Header) (Delta, error) {
+	// Parsed up front, ahead of the signature check, purely so the
+	// notification type is available to label webhook_requests_total even
+	// when the payload turns out to be unauthorized or otherwise rejected.
+	// Nothing below trusts this parse for anything but that label until
+	// the signature has been verified.
+	var notification cloudinaryNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return Delta{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if !s.signatureDisabled {
+		if err := s.verifySignature(payload, headers); err != nil {
+			return Delta{NotificationType: notification.NotificationType}, fmt.Errorf("%w: %v", ErrUnauthorizedNotification, err)
+		}
+	}
+
+	logCtx(ctx, "Webhook: Parsed notification: %+v", notification)
+
+	// Curl smoke-tests send {"public_id": "test"} with no notification_type.
+	if notification.PublicID == "test" {
+		logCtx(ctx, "Webhook: Detected test request, forcing full refresh")
+		return Delta{FullResync: true, NotificationType: "test"}, nil
+	}
+
+	if notification.ResourceType != "video" {
+		logCtx(ctx, "Webhook: Ignoring non-video resource: %s", notification.ResourceType)
+		return Delta{NotificationType: notification.NotificationType}, nil
+	}
+
+	switch notification.NotificationType {
+	case "upload", "update":
+		if notification.PublicID == "" {
+			return Delta{NotificationType: notification.NotificationType}, fmt.Errorf("notification missing public_id")
+		}
+		return Delta{Ops: []DeltaOp{upsertOp(mergeNotificationIntoTrack(notification))}, NotificationType: notification.NotificationType}, nil
+
+	case "delete":
+		if notification.PublicID == "" {
+			return Delta{NotificationType: notification.NotificationType}, fmt.Errorf("notification missing public_id")
+		}
+		return Delta{Ops: []DeltaOp{deleteOp(notification.PublicID)}, NotificationType: notification.NotificationType}, nil
+
+	case "rename":
+		if notification.FromPublicID == "" || notification.ToPublicID == "" {
+			return Delta{NotificationType: notification.NotificationType}, fmt.Errorf("rename notification missing from_public_id/to_public_id")
+		}
+		trackCacheMux.RLock()
+		resource, ok := trackCache[notification.FromPublicID]
+		trackCacheMux.RUnlock()
+		if !ok {
+			logCtx(ctx, "Webhook: Rename source %s not in cache, falling back to full refresh", notification.FromPublicID)
+			return Delta{FullResync: true, NotificationType: notification.NotificationType}, nil
+		}
+		resource.PublicID = notification.ToPublicID
+		resource.Title = titleFromPublicID(notification.ToPublicID)
+		return Delta{
+			Ops:              []DeltaOp{deleteOp(notification.FromPublicID), upsertOp(resource)},
+			NotificationType: notification.NotificationType,
+		}, nil
+
+	default:
+		logCtx(ctx, "Webhook: Unknown notification type %s, falling back to full refresh", notification.NotificationType)
+		return Delta{FullResync: true, NotificationType: notification.NotificationType}, nil
+	}
+}
+
+// verifySignature checks the X-Cld-Signature/X-Cld-Timestamp headers
+// against the Cloudinary HMAC scheme: hash(body + timestamp + api_secret).
+func (s *cloudinarySource) verifySignature(body []byte, headers http.Header) error {
+	signature := headers.Get("X-Cld-Signature")
+	timestamp := headers.Get("X-Cld-Timestamp")
+	if signature == "" || timestamp == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > s.signatureTolerance || age < -s.signatureTolerance {
+		return fmt.Errorf("timestamp outside tolerance: %v old", age)
+	}
+
+	payload := append(append([]byte{}, body...), []byte(timestamp+s.apiSecret)...)
+
+	var sum []byte
+	switch strings.ToLower(s.signatureAlgo) {
+	case "", "sha1":
+		h := sha1.Sum(payload)
+		sum = h[:]
+	case "sha256":
+		h := sha256.Sum256(payload)
+		sum = h[:]
+	default:
+		return fmt.Errorf("unsupported WEBHOOK_SIGNATURE_ALGO: %s", s.signatureAlgo)
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !hmac.Equal(sum, given) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
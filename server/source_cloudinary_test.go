@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeaders(t *testing.T, algo, apiSecret string, body []byte, ts time.Time) http.Header {
+	t.Helper()
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	payload := append(append([]byte{}, body...), []byte(timestamp+apiSecret)...)
+
+	var sum []byte
+	switch algo {
+	case "", "sha1":
+		h := sha1.Sum(payload)
+		sum = h[:]
+	case "sha256":
+		h := sha256.Sum256(payload)
+		sum = h[:]
+	}
+
+	headers := http.Header{}
+	headers.Set("X-Cld-Signature", hex.EncodeToString(sum))
+	headers.Set("X-Cld-Timestamp", timestamp)
+	return headers
+}
+
+func newTestCloudinarySource(algo string, tolerance time.Duration) *cloudinarySource {
+	return &cloudinarySource{
+		cloudName:          "test-cloud",
+		apiKey:             "key",
+		apiSecret:          "secret",
+		signatureAlgo:      algo,
+		signatureTolerance: tolerance,
+	}
+}
+
+func TestVerifySignatureAcceptsValidSha1(t *testing.T) {
+	s := newTestCloudinarySource("", webhookSignatureTolerance())
+	body := []byte(`{"public_id":"my-music/song"}`)
+	headers := signedHeaders(t, "", s.apiSecret, body, time.Now())
+
+	if err := s.verifySignature(body, headers); err != nil {
+		t.Fatalf("verifySignature() = %v, want nil", err)
+	}
+}
+
+func TestVerifySignatureAcceptsValidSha256(t *testing.T) {
+	s := newTestCloudinarySource("sha256", webhookSignatureTolerance())
+	body := []byte(`{"public_id":"my-music/song"}`)
+	headers := signedHeaders(t, "sha256", s.apiSecret, body, time.Now())
+
+	if err := s.verifySignature(body, headers); err != nil {
+		t.Fatalf("verifySignature() = %v, want nil", err)
+	}
+}
+
+func TestVerifySignatureRejectsBadSignature(t *testing.T) {
+	s := newTestCloudinarySource("", webhookSignatureTolerance())
+	body := []byte(`{"public_id":"my-music/song"}`)
+	headers := signedHeaders(t, "", "wrong-secret", body, time.Now())
+
+	if err := s.verifySignature(body, headers); err == nil {
+		t.Fatal("verifySignature() = nil, want error for mismatched signature")
+	}
+}
+
+func TestVerifySignatureRejectsTamperedBody(t *testing.T) {
+	s := newTestCloudinarySource("", webhookSignatureTolerance())
+	body := []byte(`{"public_id":"my-music/song"}`)
+	headers := signedHeaders(t, "", s.apiSecret, body, time.Now())
+
+	if err := s.verifySignature([]byte(`{"public_id":"my-music/other"}`), headers); err == nil {
+		t.Fatal("verifySignature() = nil, want error for tampered body")
+	}
+}
+
+func TestVerifySignatureRejectsOutsideTolerance(t *testing.T) {
+	tolerance := 5 * time.Minute
+	s := newTestCloudinarySource("", tolerance)
+	body := []byte(`{"public_id":"my-music/song"}`)
+	headers := signedHeaders(t, "", s.apiSecret, body, time.Now().Add(-tolerance-time.Minute))
+
+	if err := s.verifySignature(body, headers); err == nil {
+		t.Fatal("verifySignature() = nil, want error for timestamp outside tolerance")
+	}
+}
+
+func TestVerifySignatureRespectsConfiguredTolerance(t *testing.T) {
+	body := []byte(`{"public_id":"my-music/song"}`)
+	ts := time.Now().Add(-2 * time.Minute)
+	headers := signedHeaders(t, "", "secret", body, ts)
+
+	strict := newTestCloudinarySource("", time.Minute)
+	if err := strict.verifySignature(body, headers); err == nil {
+		t.Fatal("verifySignature() = nil, want error: 2m old timestamp exceeds 1m tolerance")
+	}
+
+	lenient := newTestCloudinarySource("", 5*time.Minute)
+	if err := lenient.verifySignature(body, headers); err != nil {
+		t.Fatalf("verifySignature() = %v, want nil: 2m old timestamp is within 5m tolerance", err)
+	}
+}
+
+func TestVerifySignatureRejectsMissingHeaders(t *testing.T) {
+	s := newTestCloudinarySource("", webhookSignatureTolerance())
+	if err := s.verifySignature([]byte(`{}`), http.Header{}); err == nil {
+		t.Fatal("verifySignature() = nil, want error for missing headers")
+	}
+}
+
+// webhookSignatureTolerance returns the default tolerance for tests that
+// don't care about the exact value.
+func webhookSignatureTolerance() time.Duration {
+	return defaultWebhookSignatureTolerance
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// localSource lists tracks from a directory on disk and watches it with
+// fsnotify, for self-hosted setups with no external storage provider.
+// There's no webhook to consume for a local filesystem, so it reports
+// changes to main via Watch instead of HandleNotification.
+type localSource struct {
+	dir string
+}
+
+func newLocalSource() (*localSource, error) {
+	dir := os.Getenv("LOCAL_TRACKS_DIR")
+	if dir == "" {
+		return nil, fmt.Errorf("LOCAL_TRACKS_DIR is required when TRACK_SOURCE=local")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("LOCAL_TRACKS_DIR %s: %w", dir, err)
+	}
+	return &localSource{dir: dir}, nil
+}
+
+func (s *localSource) Name() string { return "local" }
+
+func (s *localSource) List(ctx context.Context) ([]Track, error) {
+	logCtx(ctx, "Listing tracks under %s", s.dir)
+
+	var tracks []Track
+	err := filepath.WalkDir(s.dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		tracks = append(tracks, trackFromPath(p))
+		return nil
+	})
+	if err != nil {
+		logErrorCtx(ctx, "Error walking %s: %v", s.dir, err)
+		return nil, err
+	}
+
+	logCtx(ctx, "Successfully listed %d tracks", len(tracks))
+	return tracks, nil
+}
+
+func trackFromPath(p string) Track {
+	base := filepath.Base(p)
+	ext := strings.TrimPrefix(filepath.Ext(base), ".")
+	publicID := strings.TrimSuffix(base, filepath.Ext(base))
+	return Track{
+		PublicID: publicID,
+		Format:   ext,
+		Type:     "upload",
+		Title:    titleFromPublicID(publicID),
+	}
+}
+
+// HandleNotification is unused for local storage: filesystem changes are
+// observed directly via Watch rather than delivered to the webhook
+// endpoint. It's implemented only to satisfy TrackSource.
+func (s *localSource) HandleNotification(ctx context.Context, payload []byte, headers http.Header) (Delta, error) {
+	return Delta{NotificationType: "unsupported"}, fmt.Errorf("local track source does not accept webhook notifications")
+}
+
+// addDirRecursive registers watcher on root and every subdirectory under
+// it, mirroring the recursive walk List does via filepath.WalkDir. fsnotify
+// watches are not recursive on their own, so without this a file added
+// under a nested subdirectory would never produce an event.
+func addDirRecursive(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(p)
+	})
+}
+
+// Watch satisfies watchableSource: it blocks, translating fsnotify events
+// under dir into cache deltas, until ctx is cancelled.
+func (s *localSource) Watch(ctx context.Context, onDelta func(Delta)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := addDirRecursive(watcher, s.dir); err != nil {
+		return fmt.Errorf("watching %s: %w", s.dir, err)
+	}
+
+	logCtx(ctx, "Watching %s for changes (recursively)", s.dir)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+				// A newly created subdirectory needs to be watched itself
+				// (fsnotify doesn't follow directories into existence on
+				// its own), so files added under it aren't missed.
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addDirRecursive(watcher, event.Name); err != nil {
+						logErrorCtx(ctx, "fsnotify: failed to watch new directory %s: %v", event.Name, err)
+					}
+					continue
+				}
+				onDelta(Delta{Ops: []DeltaOp{upsertOp(trackFromPath(event.Name))}})
+			case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+				onDelta(Delta{Ops: []DeltaOp{deleteOp(trackFromPath(event.Name).PublicID)}})
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logErrorCtx(ctx, "fsnotify error watching %s: %v", s.dir, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Source lists and watches objects under a prefix in an S3-compatible
+// bucket (AWS S3 or MinIO), consuming S3 "Object Created"/"Object Removed"
+// event notifications delivered to the webhook endpoint.
+type s3Source struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3Source() (*s3Source, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required when TRACK_SOURCE=s3")
+	}
+	prefix := os.Getenv("S3_PREFIX")
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &s3Source{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *s3Source) Name() string { return "s3" }
+
+func (s *s3Source) List(ctx context.Context) ([]Track, error) {
+	logCtx(ctx, "Listing s3://%s/%s", s.bucket, s.prefix)
+
+	var tracks []Track
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logErrorCtx(ctx, "Error listing s3 objects: %v", err)
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			tracks = append(tracks, trackFromKey(aws.ToString(object.Key)))
+		}
+	}
+
+	logCtx(ctx, "Successfully listed %d tracks", len(tracks))
+	return tracks, nil
+}
+
+// trackFromKey derives a Track from an S3 object key, mirroring how
+// CloudinaryResource derives identity from public_id/format.
+func trackFromKey(key string) Track {
+	base := path.Base(key)
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	publicID := strings.TrimSuffix(base, path.Ext(base))
+	return Track{
+		PublicID: publicID,
+		Format:   ext,
+		Type:     "upload",
+		Title:    titleFromPublicID(publicID),
+	}
+}
+
+// s3EventNotification is the subset of the S3 event notification envelope
+// (https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html)
+// we need to keep the cache in sync.
+type s3EventNotification struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+func (s *s3Source) HandleNotification(ctx context.Context, payload []byte, headers http.Header) (Delta, error) {
+	var notification s3EventNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return Delta{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	// Appended in the order the records arrive in, so applyDelta replays
+	// them in the same order: a notification can bundle a remove and a
+	// re-upload of the same key, and whichever one comes last here is
+	// what should win in the cache.
+	var delta Delta
+	eventType := ""
+	for _, record := range notification.Records {
+		key := record.S3.Object.Key
+		if key == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(record.EventName, "ObjectCreated:"):
+			delta.Ops = append(delta.Ops, upsertOp(trackFromKey(key)))
+			eventType = mergeEventType(eventType, "object_created")
+		case strings.HasPrefix(record.EventName, "ObjectRemoved:"):
+			delta.Ops = append(delta.Ops, deleteOp(trackFromKey(key).PublicID))
+			eventType = mergeEventType(eventType, "object_removed")
+		default:
+			logCtx(ctx, "S3 webhook: ignoring event %s for %s", record.EventName, key)
+		}
+	}
+	delta.NotificationType = eventType
+
+	if len(delta.Ops) == 0 {
+		return delta, fmt.Errorf("no applicable records in notification")
+	}
+	return delta, nil
+}
+
+// mergeEventType folds a newly observed record event into the type label
+// for the whole notification: empty becomes the first event seen, a
+// second distinct event collapses to "mixed" since a single notification
+// can bundle multiple S3 records.
+func mergeEventType(current, next string) string {
+	switch {
+	case current == "":
+		return next
+	case current == next:
+		return current
+	default:
+		return "mixed"
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultAutocertCacheDir is where autocert caches issued certificates
+// when TLS_CACHE_DIR isn't set.
+const defaultAutocertCacheDir = "/var/cache/autocert"
+
+// tlsConfig bundles what main needs to start the HTTPS listener, and the
+// handler the HTTP-on-80 listener should use alongside it.
+type tlsConfig struct {
+	manual   bool
+	certFile string
+	keyFile  string
+
+	autocertMgr *autocert.Manager
+}
+
+// loadTLSConfig prefers TLS_CERT_FILE/TLS_KEY_FILE (manual mode: local
+// runs, or deployments where certs are provisioned some other way) and
+// otherwise provisions certificates automatically via ACME, restricted to
+// the hosts listed in TLS_HOSTS.
+func loadTLSConfig() (*tlsConfig, error) {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		return &tlsConfig{manual: true, certFile: certFile, keyFile: keyFile}, nil
+	}
+
+	hosts := splitHosts(os.Getenv("TLS_HOSTS"))
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("either TLS_CERT_FILE+TLS_KEY_FILE or TLS_HOSTS must be set")
+	}
+
+	cacheDir := os.Getenv("TLS_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = defaultAutocertCacheDir
+	}
+
+	return &tlsConfig{
+		autocertMgr: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Cache:      autocert.DirCache(cacheDir),
+		},
+	}, nil
+}
+
+// splitHosts parses a comma-separated TLS_HOSTS value, trimming whitespace
+// and dropping empty entries.
+func splitHosts(raw string) []string {
+	var hosts []string
+	for _, h := range strings.Split(raw, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+)
+
+// Track is the storage-agnostic representation of a single audio track,
+// regardless of which TrackSource it came from. Duration, Bitrate, Tags
+// and Context are best-effort: sources that can't supply them (a webhook
+// notification, a bare filesystem listing) leave them zero. CreatedAt is a
+// pointer for the same reason: omitempty is a no-op on a plain time.Time,
+// so a nil CreatedAt is how "unknown" is distinguished from "the epoch" in
+// the JSON response.
+type Track struct {
+	AssetID   string            `json:"asset_id"`
+	PublicID  string            `json:"public_id"`
+	Format    string            `json:"format"`
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Duration  float64           `json:"duration,omitempty"`
+	Bitrate   int               `json:"bitrate,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Context   map[string]string `json:"context,omitempty"`
+	CreatedAt *time.Time        `json:"created_at,omitempty"`
+}
+
+// titleFromPublicID derives a display title from a public_id like
+// "my-music/some-song", taking the basename.
+func titleFromPublicID(publicID string) string {
+	return path.Base(publicID)
+}
+
+// TracksResponse is the shape served from /api/tracks.
+type TracksResponse struct {
+	Resources []Track `json:"resources"`
+}
+
+// Delta describes how a notification should change the cache. FullResync
+// asks the caller to fall back to a full TrackSource.List instead of
+// trusting Ops, for notification types that don't carry enough information
+// to update the cache in place.
+type Delta struct {
+	// Ops is applied in order. A single notification can carry an upsert
+	// and a delete for the same PublicID (e.g. a batched S3 notification
+	// with a remove followed by a re-upload of the same key), so the
+	// relative order here - not a fixed "all upserts, then all deletes" -
+	// is what decides which one wins.
+	Ops        []DeltaOp
+	FullResync bool
+
+	// NotificationType is the webhook notification type the delta was
+	// derived from (e.g. "upload", "delete", "rename"), for metrics
+	// labeling only. It's set on a best-effort basis, including on
+	// sources that return an error, so webhook_requests_total can be
+	// broken down by the kind of notification an operator would
+	// actually want to alert on.
+	NotificationType string
+}
+
+// DeltaOp is a single cache mutation within a Delta. Exactly one of Upsert
+// or DeletePublicID is set.
+type DeltaOp struct {
+	Upsert         *Track
+	DeletePublicID string
+}
+
+// upsertOp builds the DeltaOp that caches t.
+func upsertOp(t Track) DeltaOp {
+	return DeltaOp{Upsert: &t}
+}
+
+// deleteOp builds the DeltaOp that removes publicID from the cache.
+func deleteOp(publicID string) DeltaOp {
+	return DeltaOp{DeletePublicID: publicID}
+}
+
+// Global cache, keyed by public_id so notifications can mutate a single
+// entry instead of forcing a full re-fetch from the TrackSource.
+var (
+	trackCache    = make(map[string]Track)
+	trackCacheMux sync.RWMutex
+	lastFetchTime time.Time
+	lastFetchMux  sync.RWMutex
+)
+
+// snapshotTracks returns the current cache as the flat slice shape the API
+// responds with.
+func snapshotTracks() TracksResponse {
+	trackCacheMux.RLock()
+	defer trackCacheMux.RUnlock()
+
+	resources := make([]Track, 0, len(trackCache))
+	for _, track := range trackCache {
+		resources = append(resources, track)
+	}
+	return TracksResponse{Resources: resources}
+}
+
+func touchLastFetchTime() {
+	lastFetchMux.Lock()
+	lastFetchTime = time.Now()
+	lastFetchMux.Unlock()
+}
+
+// refreshCache replaces the entire cache with a fresh listing from source.
+// This is comparatively expensive, so callers should prefer applyDelta for
+// notifications that carry enough information to update in place.
+func refreshCache(ctx context.Context, source TrackSource) error {
+	logCtx(ctx, "Starting cache refresh from %s...", source.Name())
+
+	start := time.Now()
+	tracks, err := source.List(ctx)
+	recordFetchDuration(time.Since(start))
+	if err != nil {
+		logErrorCtx(ctx, "Cache refresh failed: %v", err)
+		return err
+	}
+
+	trackCacheMux.Lock()
+	logCtx(ctx, "Previous cache had %d tracks", len(trackCache))
+	trackCache = make(map[string]Track, len(tracks))
+	for _, track := range tracks {
+		trackCache[track.PublicID] = track
+	}
+	logCtx(ctx, "New cache has %d tracks", len(trackCache))
+	trackCacheMux.Unlock()
+
+	touchLastFetchTime()
+
+	logCtx(ctx, "Cache refresh completed successfully")
+	return nil
+}
+
+// applyDelta mutates the cache in place, or falls back to a full refresh
+// when the delta says it couldn't be computed incrementally.
+func applyDelta(ctx context.Context, source TrackSource, delta Delta) error {
+	if delta.FullResync {
+		return refreshCache(ctx, source)
+	}
+
+	trackCacheMux.Lock()
+	for _, op := range delta.Ops {
+		switch {
+		case op.Upsert != nil:
+			trackCache[op.Upsert.PublicID] = *op.Upsert
+		case op.DeletePublicID != "":
+			delete(trackCache, op.DeletePublicID)
+		}
+	}
+	trackCacheMux.Unlock()
+
+	touchLastFetchTime()
+	return nil
+}
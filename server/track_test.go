@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func resetTrackCache(t *testing.T, initial map[string]Track) {
+	t.Helper()
+	trackCacheMux.Lock()
+	trackCache = make(map[string]Track, len(initial))
+	for k, v := range initial {
+		trackCache[k] = v
+	}
+	trackCacheMux.Unlock()
+	t.Cleanup(func() {
+		trackCacheMux.Lock()
+		trackCache = make(map[string]Track)
+		trackCacheMux.Unlock()
+	})
+}
+
+func TestApplyDeltaUpsertsAndDeletesInPlace(t *testing.T) {
+	resetTrackCache(t, map[string]Track{
+		"my-music/keep":   {PublicID: "my-music/keep", Title: "keep"},
+		"my-music/remove": {PublicID: "my-music/remove", Title: "remove"},
+	})
+
+	delta := Delta{
+		Ops: []DeltaOp{
+			upsertOp(Track{PublicID: "my-music/new", Title: "new"}),
+			deleteOp("my-music/remove"),
+		},
+	}
+	if err := applyDelta(context.Background(), nil, delta); err != nil {
+		t.Fatalf("applyDelta() = %v, want nil", err)
+	}
+
+	trackCacheMux.RLock()
+	defer trackCacheMux.RUnlock()
+
+	if _, ok := trackCache["my-music/remove"]; ok {
+		t.Error("expected my-music/remove to be deleted from cache")
+	}
+	if _, ok := trackCache["my-music/keep"]; !ok {
+		t.Error("expected my-music/keep to remain in cache")
+	}
+	if _, ok := trackCache["my-music/new"]; !ok {
+		t.Error("expected my-music/new to be upserted into cache")
+	}
+}
+
+func TestApplyDeltaUpsertOverwritesExistingEntry(t *testing.T) {
+	resetTrackCache(t, map[string]Track{
+		"my-music/song": {PublicID: "my-music/song", Title: "old title"},
+	})
+
+	delta := Delta{Ops: []DeltaOp{upsertOp(Track{PublicID: "my-music/song", Title: "new title"})}}
+	if err := applyDelta(context.Background(), nil, delta); err != nil {
+		t.Fatalf("applyDelta() = %v, want nil", err)
+	}
+
+	trackCacheMux.RLock()
+	defer trackCacheMux.RUnlock()
+
+	if got := trackCache["my-music/song"].Title; got != "new title" {
+		t.Errorf("Title = %q, want %q", got, "new title")
+	}
+}
+
+func TestApplyDeltaRespectsOpOrderForSameKey(t *testing.T) {
+	resetTrackCache(t, nil)
+
+	// A single notification can batch a remove followed by a re-upload of
+	// the same key (or vice versa); whichever op comes last must win,
+	// regardless of a fixed "upserts then deletes" order.
+	deleteThenUpsert := Delta{Ops: []DeltaOp{
+		deleteOp("my-music/song"),
+		upsertOp(Track{PublicID: "my-music/song", Title: "recreated"}),
+	}}
+	if err := applyDelta(context.Background(), nil, deleteThenUpsert); err != nil {
+		t.Fatalf("applyDelta() = %v, want nil", err)
+	}
+	if _, ok := trackCache["my-music/song"]; !ok {
+		t.Error("expected my-music/song to survive a delete followed by an upsert")
+	}
+
+	upsertThenDelete := Delta{Ops: []DeltaOp{
+		upsertOp(Track{PublicID: "my-music/song", Title: "recreated"}),
+		deleteOp("my-music/song"),
+	}}
+	if err := applyDelta(context.Background(), nil, upsertThenDelete); err != nil {
+		t.Fatalf("applyDelta() = %v, want nil", err)
+	}
+	if _, ok := trackCache["my-music/song"]; ok {
+		t.Error("expected my-music/song to be gone after an upsert followed by a delete")
+	}
+}
+
+// fakeFullResyncSource is a TrackSource stub used only to verify
+// applyDelta falls back to a full refreshCache when Delta.FullResync is
+// set; its List return becomes the entire cache contents.
+type fakeFullResyncSource struct {
+	tracks []Track
+}
+
+func (s *fakeFullResyncSource) Name() string { return "fake" }
+func (s *fakeFullResyncSource) List(ctx context.Context) ([]Track, error) {
+	return s.tracks, nil
+}
+func (s *fakeFullResyncSource) HandleNotification(ctx context.Context, payload []byte, headers http.Header) (Delta, error) {
+	return Delta{}, nil
+}
+
+func TestApplyDeltaFullResyncReplacesWholeCache(t *testing.T) {
+	resetTrackCache(t, map[string]Track{
+		"my-music/stale": {PublicID: "my-music/stale"},
+	})
+
+	source := &fakeFullResyncSource{tracks: []Track{{PublicID: "my-music/fresh"}}}
+	if err := applyDelta(context.Background(), source, Delta{FullResync: true}); err != nil {
+		t.Fatalf("applyDelta() = %v, want nil", err)
+	}
+
+	trackCacheMux.RLock()
+	defer trackCacheMux.RUnlock()
+
+	if _, ok := trackCache["my-music/stale"]; ok {
+		t.Error("expected stale entry to be gone after full resync")
+	}
+	if _, ok := trackCache["my-music/fresh"]; !ok {
+		t.Error("expected fresh entry from full resync")
+	}
+}